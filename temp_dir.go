@@ -182,6 +182,57 @@ func TempCloneChdir(src string) (string, func(), error) {
 		nil
 }
 
+// TempCloneDirOpts clones a temporary directory in the same
+// fashion as TempCloneDir, but uses TreeCopyOpts instead of
+// TreeCopy, so symlinks, hardlinks, timestamps and the
+// setuid/setgid/sticky bits can be faithfully reproduced
+// according to the given CopyOpt options.
+func TempCloneDirOpts(src string, opts ...CopyOpt) (string, func(), error) {
+	root, cleanup, err := TempInitDir()
+	if err != nil {
+		return "", nil, err
+	}
+
+	err = TreeCopyOpts(src, root, opts...)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return root, cleanup, nil
+}
+
+// TempCloneChdirOpts clones a temporary directory in the same
+// fashion as TempCloneDirOpts. It also changes into the newly
+// cloned temporary directory and adds returning back to the old
+// working directory to the returned cleanup function. The
+// returned values are the same as for TempCloneChdir.
+func TempCloneChdirOpts(src string, opts ...CopyOpt) (string, func(), error) {
+	root, cleanup, err := TempCloneDirOpts(src, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	err = os.Chdir(root)
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return wd,
+		func() {
+			os.Chdir(wd)
+			cleanup()
+		},
+		nil
+}
+
 // TempCreateChdir is a combination of `TempInitChdir` and
 // `TreeCreate` functions. It creates a termporary directory,
 // changes into it, populates it fron the provided `config`
@@ -0,0 +1,85 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io"
+	"testing"
+)
+
+// TempInitDirT is the testing.T-aware counterpart of TempInitDir.
+// It registers the cleanup function with t.Cleanup and calls
+// t.Fatal instead of returning an error, so callers can write
+//
+//	dir := fst.TempInitDirT(t)
+//
+// and rely on the temporary directory being removed when the
+// test and its subtests complete.
+func TempInitDirT(t *testing.T) string {
+	t.Helper()
+
+	dir, cleanup, err := TempInitDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(cleanup)
+
+	return dir
+}
+
+// TempInitChdirT is the testing.T-aware counterpart of TempInitChdir.
+// It registers the cleanup function with t.Cleanup and calls
+// t.Fatal instead of returning an error. As with TempInitChdir,
+// the returned string is the previous working directory, which
+// the cleanup function changes back into.
+func TempInitChdirT(t *testing.T) string {
+	t.Helper()
+
+	wd, cleanup, err := TempInitChdir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(cleanup)
+
+	return wd
+}
+
+// TempCloneDirT is the testing.T-aware counterpart of TempCloneDir.
+// It registers the cleanup function with t.Cleanup and calls
+// t.Fatal instead of returning an error, so callers can write
+//
+//	dir := fst.TempCloneDirT(t, src)
+func TempCloneDirT(t *testing.T, src string) string {
+	t.Helper()
+
+	dir, cleanup, err := TempCloneDir(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(cleanup)
+
+	return dir
+}
+
+// TempCreateChdirT is the testing.T-aware counterpart of
+// TempCreateChdir. It registers the cleanup function with
+// t.Cleanup and calls t.Fatal instead of returning an error. As
+// with TempCreateChdir, the returned string is the previous
+// working directory, which the cleanup function changes back
+// into.
+func TempCreateChdirT(t *testing.T, config io.Reader) string {
+	t.Helper()
+
+	wd, cleanup, err := TempCreateChdir(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(cleanup)
+
+	return wd
+}
@@ -0,0 +1,140 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ChangeKind describes how a path differs between the two trees
+// compared by TreeChanges.
+type ChangeKind int
+
+const (
+	// ChangeAdded means the path exists in the second tree but
+	// not in the first.
+	ChangeAdded ChangeKind = iota
+
+	// ChangeRemoved means the path exists in the first tree but
+	// not in the second.
+	ChangeRemoved
+
+	// ChangeModified means the path exists in both trees, but
+	// the provided comparators rank it differently in one tree
+	// than in the other.
+	ChangeModified
+)
+
+// Change describes a single path which differs between the two
+// trees compared by TreeChanges. A and B are the path's
+// *FileInfoPath in the first and second tree respectively; one
+// of them is nil for ChangeAdded and ChangeRemoved entries.
+type Change struct {
+	Kind ChangeKind
+	Path string
+	A    *FileInfoPath
+	B    *FileInfoPath
+}
+
+// TreeChanges double-walks the directory trees rooted at a and b
+// in lexical order and returns the list of paths which differ
+// between them, using the same comparator machinery as Less. A
+// path present in both trees counts as ChangeModified if and
+// only if Less(a, b, cmps...) || Less(b, a, cmps...); a path
+// present in only one of the trees is reported as ChangeAdded or
+// ChangeRemoved accordingly.
+//
+// Unlike TreeDiff, which reports a single pass/fail result,
+// TreeChanges lets callers assert exactly which paths differ and
+// how.
+func TreeChanges(a, b string, cmps ...FileRank) ([]Change, error) {
+
+	aPaths, aEntries, err := treeEntries(a)
+	if err != nil {
+		return nil, err
+	}
+
+	bPaths, bEntries, err := treeEntries(b)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, 0)
+
+	i, j := 0, 0
+	for i < len(aPaths) && j < len(bPaths) {
+		ap, bp := aPaths[i], bPaths[j]
+
+		switch {
+		case ap < bp:
+			changes = append(changes, Change{Kind: ChangeRemoved, Path: ap, A: aEntries[ap]})
+			i++
+
+		case ap > bp:
+			changes = append(changes, Change{Kind: ChangeAdded, Path: bp, B: bEntries[bp]})
+			j++
+
+		default:
+			af, bf := aEntries[ap], bEntries[bp]
+			if Less(af, bf, cmps...) || Less(bf, af, cmps...) {
+				changes = append(changes, Change{Kind: ChangeModified, Path: ap, A: af, B: bf})
+			}
+			i++
+			j++
+		}
+	}
+
+	for ; i < len(aPaths); i++ {
+		changes = append(changes, Change{Kind: ChangeRemoved, Path: aPaths[i], A: aEntries[aPaths[i]]})
+	}
+
+	for ; j < len(bPaths); j++ {
+		changes = append(changes, Change{Kind: ChangeAdded, Path: bPaths[j], B: bEntries[bPaths[j]]})
+	}
+
+	return changes, nil
+}
+
+// treeEntries walks root and returns the paths found under it,
+// relative to root and sorted lexically, along with a map from
+// each relative path to its *FileInfoPath.
+func treeEntries(root string) ([]string, map[string]*FileInfoPath, error) {
+
+	entries := make(map[string]*FileInfoPath)
+	paths := make([]string, 0)
+
+	err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		fip, err := NewFileInfoPath(path)
+		if err != nil {
+			return err
+		}
+
+		entries[rel] = fip
+		paths = append(paths, rel)
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.Strings(paths)
+
+	return paths, entries, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTreeChangesDetectsAdded(t *testing.T) {
+	a := t.TempDir()
+	b := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(a, "same.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b, "same.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b, "new.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := TreeChanges(a, b, ByName, BySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d: %+v", len(changes), changes)
+	}
+
+	if changes[0].Kind != ChangeAdded {
+		t.Fatalf("expected ChangeAdded, got %v", changes[0].Kind)
+	}
+
+	if changes[0].Path != "new.txt" {
+		t.Fatalf("expected path %q, got %q", "new.txt", changes[0].Path)
+	}
+
+	if changes[0].A != nil || changes[0].B == nil {
+		t.Fatal("expected a ChangeAdded entry to carry only B")
+	}
+}
+
+func TestTreeChangesDetectsRemoved(t *testing.T) {
+	a := t.TempDir()
+	b := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(a, "same.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b, "same.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(a, "gone.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := TreeChanges(a, b, ByName, BySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d: %+v", len(changes), changes)
+	}
+
+	if changes[0].Kind != ChangeRemoved {
+		t.Fatalf("expected ChangeRemoved, got %v", changes[0].Kind)
+	}
+
+	if changes[0].Path != "gone.txt" {
+		t.Fatalf("expected path %q, got %q", "gone.txt", changes[0].Path)
+	}
+
+	if changes[0].B != nil || changes[0].A == nil {
+		t.Fatal("expected a ChangeRemoved entry to carry only A")
+	}
+}
+
+func TestTreeChangesDetectsModified(t *testing.T) {
+	a := t.TempDir()
+	b := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(a, "file.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b, "file.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := TreeChanges(a, b, BySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d: %+v", len(changes), changes)
+	}
+
+	if changes[0].Kind != ChangeModified {
+		t.Fatalf("expected ChangeModified, got %v", changes[0].Kind)
+	}
+
+	if changes[0].Path != "file.txt" {
+		t.Fatalf("expected path %q, got %q", "file.txt", changes[0].Path)
+	}
+
+	if changes[0].A == nil || changes[0].B == nil {
+		t.Fatal("expected a ChangeModified entry to carry both A and B")
+	}
+}
+
+func TestTreeChangesNoChangesWhenEquivalent(t *testing.T) {
+	a := t.TempDir()
+	b := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(a, "file.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(b, "file.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := TreeChanges(a, b, ByName, BySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 0 {
+		t.Fatalf("expected no changes between equivalent trees, got %+v", changes)
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// copyOptions holds the effective settings built up from the
+// CopyOpt functions passed to TreeCopyOpts.
+type copyOptions struct {
+	symlinks    bool
+	hardlinks   bool
+	times       bool
+	specialBits bool
+}
+
+// CopyOpt configures the behavior of TreeCopyOpts.
+type CopyOpt func(*copyOptions)
+
+// PreserveSymlinks makes TreeCopyOpts reproduce symlinks found in
+// the source tree as symlinks in the destination, rather than
+// copying the content of the files they point to.
+func PreserveSymlinks() CopyOpt {
+	return func(o *copyOptions) {
+		o.symlinks = true
+	}
+}
+
+// PreserveHardlinks makes TreeCopyOpts track source files by
+// device and inode, so that files which are hardlinked to each
+// other in the source tree are cloned as hardlinks of each other
+// in the destination, rather than as independent copies.
+func PreserveHardlinks() CopyOpt {
+	return func(o *copyOptions) {
+		o.hardlinks = true
+	}
+}
+
+// PreserveTimes makes TreeCopyOpts apply the source files' and
+// directories' modification and access times to their copies.
+// Directory times are applied only after all of a directory's
+// descendants have been copied, so that the copy itself does not
+// bump the directory's mtime back to the current time.
+//
+// Symlinks copied under PreserveSymlinks are exempt: the
+// standard library has no way to set a symlink's own time
+// without following it, and following it would either mutate the
+// time of whatever the link points at or fail outright for a
+// dangling link, so their time is left as whatever os.Symlink
+// gives it.
+func PreserveTimes() CopyOpt {
+	return func(o *copyOptions) {
+		o.times = true
+	}
+}
+
+// PreserveSpecialBits makes TreeCopyOpts apply the setuid,
+// setgid, and sticky bits from the source permissions, in
+// addition to the basic 9-bit permissions which TreeCopy always
+// preserves.
+func PreserveSpecialBits() CopyOpt {
+	return func(o *copyOptions) {
+		o.specialBits = true
+	}
+}
+
+// TreeCopyOpts copies the directory tree rooted at src into dst,
+// the same way TreeCopy does, but with finer control over how
+// symlinks, hardlinks, timestamps and the setuid/setgid/sticky
+// permission bits are handled. With no options given, its
+// behavior matches TreeCopy: symlinks (including symlinks to
+// directories) are followed and their targets' content is
+// copied, hardlinked source files become independent copies, and
+// only the basic 9-bit permissions are preserved.
+//
+// Following a symlink into a directory that is its own ancestor
+// (e.g. a symlink to ".", or to a parent directory) is reported
+// as an error instead of recursing forever.
+func TreeCopyOpts(src, dst string, opts ...CopyOpt) error {
+
+	o := &copyOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	st := &copyState{
+		o:         o,
+		inodes:    make(map[inodeKey]string),
+		ancestors: make(map[inodeKey]bool),
+	}
+
+	if err := st.copyTree(src, dst); err != nil {
+		return err
+	}
+
+	// dirTimes is built up in post-order - a directory is only
+	// appended once every descendant it contains has already
+	// been copied and had its own times applied - so applying it
+	// in the order collected means a directory's mtime is always
+	// set after its children, and copying those children can
+	// never bump it back up.
+	for _, entry := range st.dirTimes {
+		if err := applyTimes(entry.fi, entry.dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyState carries the bookkeeping that needs to survive across
+// the whole recursive copy: hardlink identities seen so far, the
+// directories still waiting to have their times applied, and the
+// chain of source directories currently being copied, used to
+// detect symlink loops.
+type copyState struct {
+	o         *copyOptions
+	inodes    map[inodeKey]string
+	dirTimes  []dirTimeEntry
+	ancestors map[inodeKey]bool
+}
+
+// inodeKey identifies a source file by device and inode, so that
+// hardlinked files can be recognized regardless of their path,
+// and so that a directory being followed through a symlink can
+// be recognized as its own ancestor.
+type inodeKey struct {
+	dev uint64
+	ino uint64
+}
+
+// dirTimeEntry records a directory whose times still need to be
+// applied once all of its descendants have been written.
+type dirTimeEntry struct {
+	fi  os.FileInfo
+	dst string
+}
+
+// copyTree copies the entries of the already-existing directory
+// src into the already-existing directory dst, recursing into
+// subdirectories and, unless PreserveSymlinks is given, into
+// symlinked subdirectories as well.
+func (st *copyState) copyTree(src, dst string) error {
+
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		srcPath := filepath.Join(src, entry.Name())
+		dstPath := filepath.Join(dst, entry.Name())
+
+		fi, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		if err := st.copyEntry(srcPath, dstPath, fi); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyEntry copies a single tree entry, given its Lstat-based
+// fi, dispatching between symlinks, directories and regular
+// files.
+func (st *copyState) copyEntry(src, dst string, fi os.FileInfo) error {
+
+	if fi.Mode()&os.ModeSymlink == 0 {
+		if fi.IsDir() {
+			return st.copyDir(src, dst, fi)
+		}
+		return copyFile(src, dst, fi, st.o, st.inodes)
+	}
+
+	if st.o.symlinks {
+		return copySymlink(src, dst, fi, st.o)
+	}
+
+	// Not preserving symlinks: follow the link and copy whatever
+	// it resolves to, the same way TreeCopy does.
+	target, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+
+	if target.IsDir() {
+		realSrc, err := filepath.EvalSymlinks(src)
+		if err != nil {
+			return err
+		}
+		return st.copyDir(realSrc, dst, target)
+	}
+
+	return copyFile(src, dst, target, st.o, st.inodes)
+}
+
+// copySymlink reproduces src as a symlink at dst. PreserveTimes
+// is deliberately not applied here: os.Chtimes has no symlink
+// variant in the standard library, so it would follow the new
+// link and set the *target's* time instead - for an in-tree
+// target that mutates the very file being copied, and for a
+// dangling target it simply fails. A symlink's own mtime is left
+// at whatever os.Symlink gives it.
+func copySymlink(src, dst string, fi os.FileInfo, o *copyOptions) error {
+
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+
+	return os.Symlink(target, dst)
+}
+
+// copyDir creates dst as a directory with fi's permissions,
+// recursively copies the children found under src into it, and,
+// if times are being preserved, queues fi/dst so that dst's own
+// times are applied only after all of its children have been
+// written.
+//
+// src is tracked by device and inode for the duration of the
+// recursive copyTree call below, so that a symlink resolving
+// back to src or one of its ancestors - which would otherwise
+// recurse forever - is instead reported as an error.
+func (st *copyState) copyDir(src, dst string, fi os.FileInfo) error {
+
+	key, tracked := dirKey(fi)
+	if tracked {
+		if st.ancestors[key] {
+			return fmt.Errorf("fst: symlink loop detected copying %s", src)
+		}
+		st.ancestors[key] = true
+		defer delete(st.ancestors, key)
+	}
+
+	if err := os.Mkdir(dst, fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if err := applyMode(dst, fi, st.o); err != nil {
+		return err
+	}
+
+	if err := st.copyTree(src, dst); err != nil {
+		return err
+	}
+
+	if st.o.times {
+		st.dirTimes = append(st.dirTimes, dirTimeEntry{fi: fi, dst: dst})
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string, fi os.FileInfo, o *copyOptions, inodes map[inodeKey]string) error {
+
+	if o.hardlinks {
+		if key, ok := fileInode(fi); ok {
+			if linked, found := inodes[key]; found {
+				return os.Link(linked, dst)
+			}
+			inodes[key] = dst
+		}
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+
+	if err := applyMode(dst, fi, o); err != nil {
+		return err
+	}
+
+	if o.times {
+		return applyTimes(fi, dst)
+	}
+
+	return nil
+}
+
+func applyMode(dst string, fi os.FileInfo, o *copyOptions) error {
+
+	perm := fi.Mode().Perm()
+
+	if o.specialBits {
+		perm |= fi.Mode() & (os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	}
+
+	return os.Chmod(dst, perm)
+}
+
+// applyTimes applies fi's modification time, and access time
+// where available, to dst. fi must already describe the file
+// dst was copied from - for a followed symlink that means the
+// resolved target's info, not the symlink's own.
+func applyTimes(fi os.FileInfo, dst string) error {
+
+	atime := fi.ModTime()
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		atime = time.Unix(st.Atim.Sec, st.Atim.Nsec)
+	}
+
+	return os.Chtimes(dst, atime, fi.ModTime())
+}
+
+// dirKey returns the device/inode identity of a directory, used
+// to detect symlink loops. It is unconditional, unlike
+// fileInode, since a directory need not be multiply linked to be
+// its own ancestor through a symlink.
+func dirKey(fi os.FileInfo) (inodeKey, bool) {
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return inodeKey{}, false
+	}
+
+	return inodeKey{dev: uint64(st.Dev), ino: st.Ino}, true
+}
+
+// fileInode returns the device/inode identity of a regular file,
+// but only when it actually has more than one hardlink, since
+// that is the only case PreserveHardlinks needs to track.
+func fileInode(fi os.FileInfo) (inodeKey, bool) {
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok || st.Nlink < 2 {
+		return inodeKey{}, false
+	}
+
+	return inodeKey{dev: uint64(st.Dev), ino: st.Ino}, true
+}
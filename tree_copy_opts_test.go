@@ -0,0 +1,273 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTreeCopyOptsFollowsSymlinkToFileByDefault(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	target := filepath.Join(src, "target.txt")
+	if err := os.WriteFile(target, []byte("hi"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(src, "link.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TreeCopyOpts(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(dst, "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		t.Fatal("expected link.txt to be copied as a regular file, got a symlink")
+	}
+
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected copied file to keep mode 0600, got %o", fi.Mode().Perm())
+	}
+}
+
+func TestTreeCopyOptsFollowsSymlinkToDirByDefault(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	real := filepath.Join(src, "real")
+	if err := os.Mkdir(real, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(real, "file.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(src, "link")
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TreeCopyOpts(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(dst, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 {
+		t.Fatal("expected link to be copied as a real directory, got a symlink")
+	}
+
+	if !fi.IsDir() {
+		t.Fatal("expected link to be copied as a directory")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "link", "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "hi" {
+		t.Fatalf("expected copied file content %q, got %q", "hi", data)
+	}
+}
+
+func TestTreeCopyOptsRejectsSymlinkLoop(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	loop := filepath.Join(src, "loop")
+	if err := os.Symlink(src, loop); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TreeCopyOpts(src, dst); err == nil {
+		t.Fatal("expected an error copying a symlink that loops back to an ancestor, got nil")
+	}
+}
+
+func TestTreeCopyOptsPreserveSymlinksWithTimesLeavesSourceUntouched(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	target := filepath.Join(src, "target.txt")
+	if err := os.WriteFile(target, []byte("hi"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backdated := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(target, backdated, backdated); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(src, "link.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TreeCopyOpts(src, dst, PreserveSymlinks(), PreserveTimes()); err != nil {
+		t.Fatal(err)
+	}
+
+	targetInfo, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !targetInfo.ModTime().Equal(backdated) {
+		t.Fatalf("expected copy to leave src/target.txt mtime at %v, got %v - the copy mutated its own input", backdated, targetInfo.ModTime())
+	}
+}
+
+func TestTreeCopyOptsPreserveSymlinksWithTimesAllowsDanglingLink(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	link := filepath.Join(src, "dangling.txt")
+	if err := os.Symlink(filepath.Join(src, "does-not-exist.txt"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TreeCopyOpts(src, dst, PreserveSymlinks(), PreserveTimes()); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(dst, "dangling.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("expected dangling.txt to be copied as a symlink")
+	}
+}
+
+func TestTreeCopyOptsPreserveSymlinks(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	target := filepath.Join(src, "target.txt")
+	if err := os.WriteFile(target, []byte("hi"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	link := filepath.Join(src, "link.txt")
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TreeCopyOpts(src, dst, PreserveSymlinks()); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(filepath.Join(dst, "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("expected link.txt to remain a symlink")
+	}
+
+	got, err := os.Readlink(filepath.Join(dst, "link.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != target {
+		t.Fatalf("expected symlink target %q, got %q", target, got)
+	}
+}
+
+func TestTreeCopyOptsPreserveHardlinks(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	a := filepath.Join(src, "a.txt")
+	if err := os.WriteFile(a, []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := filepath.Join(src, "b.txt")
+	if err := os.Link(a, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TreeCopyOpts(src, dst, PreserveHardlinks()); err != nil {
+		t.Fatal(err)
+	}
+
+	aInfo, err := os.Stat(filepath.Join(dst, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bInfo, err := os.Stat(filepath.Join(dst, "b.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !os.SameFile(aInfo, bInfo) {
+		t.Fatal("expected a.txt and b.txt to be cloned as hardlinks of each other")
+	}
+}
+
+func TestTreeCopyOptsPreserveTimes(t *testing.T) {
+	src := t.TempDir()
+	dst := t.TempDir()
+
+	sub := filepath.Join(src, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(sub, "file.txt")
+	if err := os.WriteFile(file, []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backdated := time.Now().Add(-72 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, backdated, backdated); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(sub, backdated, backdated); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := TreeCopyOpts(src, dst, PreserveTimes()); err != nil {
+		t.Fatal(err)
+	}
+
+	fileInfo, err := os.Stat(filepath.Join(dst, "sub", "file.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fileInfo.ModTime().Equal(backdated) {
+		t.Fatalf("expected file mtime %v, got %v", backdated, fileInfo.ModTime())
+	}
+
+	dirInfo, err := os.Stat(filepath.Join(dst, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !dirInfo.ModTime().Equal(backdated) {
+		t.Fatalf("expected dir mtime %v, got %v - copying its children likely bumped it back up", backdated, dirInfo.ModTime())
+	}
+}
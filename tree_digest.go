@@ -0,0 +1,241 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// digestOptions holds the effective settings built up from the
+// DigestOpt functions passed to TreeDigest.
+type digestOptions struct {
+	mtime  bool
+	perm   bool
+	follow bool
+}
+
+// DigestOpt configures the behavior of TreeDigest.
+type DigestOpt func(*digestOptions)
+
+// WithMtime makes TreeDigest fold each file's and directory's
+// modification time into its digest. By default mtime is
+// ignored, since it commonly differs between otherwise identical
+// trees produced at different times.
+func WithMtime() DigestOpt {
+	return func(o *digestOptions) {
+		o.mtime = true
+	}
+}
+
+// WithoutPerm makes TreeDigest ignore the 9-bit Unix permissions
+// of files and directories. By default permission bits are part
+// of the digest.
+func WithoutPerm() DigestOpt {
+	return func(o *digestOptions) {
+		o.perm = false
+	}
+}
+
+// FollowSymlinks makes TreeDigest dereference symlinks and
+// digest the content of their targets instead of recording the
+// link itself. By default symlinks are recorded as links - their
+// target string is folded into the digest instead of their
+// content - not followed. Following a symlink that resolves back
+// to one of its own ancestor directories is reported as an
+// error instead of recursing forever, the same as TreeCopyOpts's
+// FollowSymlinks-equivalent default behavior.
+func FollowSymlinks() DigestOpt {
+	return func(o *digestOptions) {
+		o.follow = true
+	}
+}
+
+// TreeDigest produces a stable, recursive content-addressable
+// digest of the directory tree rooted at root and returns it as
+// a hex-encoded string.
+//
+// The tree is walked in sorted order. Each file contributes
+// sha256(relpath || 0x00 || "file" || 0x00 || mode&0o777 || 0x00 ||
+// mtime || 0x00 || size || 0x00 || content) to its parent, with
+// the permission bits included unless WithoutPerm is given and
+// mtime included only if WithMtime is given. Each directory
+// contributes a single sha256 over the same kind of header (with
+// "dir" as its kind) followed by the concatenation of its
+// children's raw digests, so the root digest changes if any file
+// or directory anywhere in the tree changes.
+//
+// Symlinks are recorded as their target string by default; pass
+// FollowSymlinks to dereference them and digest the target's
+// content instead.
+func TreeDigest(root string, opts ...DigestOpt) (string, error) {
+
+	o := &digestOptions{perm: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return treeDigest(root, ".", o, make(map[inodeKey]bool))
+}
+
+// TreeEqualByDigest reports whether the directory trees rooted
+// at a and b produce the same TreeDigest. It is a cheaper
+// alternative to pairwise comparators such as ByContent when all
+// that is needed is a single pass/fail tree comparison, e.g. for
+// golden-tree assertions. Any error encountered while digesting
+// either tree is reported via t.Fatal.
+func TreeEqualByDigest(t *testing.T, a, b string, opts ...DigestOpt) bool {
+	t.Helper()
+
+	digestA, err := TreeDigest(a, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestB, err := TreeDigest(b, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return digestA == digestB
+}
+
+func treeDigest(path, rel string, o *digestOptions, ancestors map[inodeKey]bool) (string, error) {
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+
+	if fi.Mode()&os.ModeSymlink != 0 && o.follow {
+		fi, err = os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	switch {
+	case fi.Mode()&os.ModeSymlink != 0:
+		return digestSymlink(path, rel)
+	case fi.IsDir():
+		return digestDir(path, rel, fi, o, ancestors)
+	default:
+		return digestFile(path, rel, fi, o)
+	}
+}
+
+// digestDir digests the directory at path. Every directory is
+// tracked by device and inode for the duration of the recursive
+// call below, so that a symlink followed under FollowSymlinks and
+// resolving back to one of its own ancestor directories is
+// reported as an error instead of recursing forever.
+func digestDir(path, rel string, fi os.FileInfo, o *digestOptions, ancestors map[inodeKey]bool) (string, error) {
+
+	if key, tracked := dirKey(fi); tracked {
+		if ancestors[key] {
+			return "", fmt.Errorf("fst: symlink loop detected digesting %s", path)
+		}
+		ancestors[key] = true
+		defer delete(ancestors, key)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	writeHeader(h, rel, "dir", fi, o)
+
+	for _, name := range names {
+		childDigest, err := treeDigest(filepath.Join(path, name), filepath.Join(rel, name), o, ancestors)
+		if err != nil {
+			return "", err
+		}
+
+		raw, err := hex.DecodeString(childDigest)
+		if err != nil {
+			return "", err
+		}
+
+		h.Write(raw)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func digestFile(path, rel string, fi os.FileInfo, o *digestOptions) (string, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	writeHeader(h, rel, "file", fi, o)
+
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(fi.Size()))
+	h.Write(size)
+	h.Write([]byte{0})
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func digestSymlink(path, rel string) (string, error) {
+
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(rel))
+	h.Write([]byte{0})
+	h.Write([]byte("symlink"))
+	h.Write([]byte{0})
+	h.Write([]byte(target))
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeHeader(h io.Writer, rel, kind string, fi os.FileInfo, o *digestOptions) {
+
+	h.Write([]byte(rel))
+	h.Write([]byte{0})
+	h.Write([]byte(kind))
+	h.Write([]byte{0})
+
+	if o.perm {
+		perm := make([]byte, 2)
+		binary.BigEndian.PutUint16(perm, uint16(fi.Mode().Perm()))
+		h.Write(perm)
+	}
+	h.Write([]byte{0})
+
+	if o.mtime {
+		mtime := make([]byte, 8)
+		binary.BigEndian.PutUint64(mtime, uint64(fi.ModTime().UnixNano()))
+		h.Write(mtime)
+	}
+	h.Write([]byte{0})
+}
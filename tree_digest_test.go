@@ -0,0 +1,179 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func buildDigestFixture(t *testing.T, perm os.FileMode) string {
+	t.Helper()
+
+	root := t.TempDir()
+
+	sub := filepath.Join(root, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(sub, "file.txt"), []byte("hi"), perm); err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestTreeDigestStableAcrossIdenticalTrees(t *testing.T) {
+	a := buildDigestFixture(t, 0644)
+	b := buildDigestFixture(t, 0644)
+
+	digestA, err := TreeDigest(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestB, err := TreeDigest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digestA != digestB {
+		t.Fatalf("expected identical trees to digest the same, got %s and %s", digestA, digestB)
+	}
+}
+
+func TestTreeDigestSensitiveToContent(t *testing.T) {
+	a := buildDigestFixture(t, 0644)
+	b := buildDigestFixture(t, 0644)
+
+	if err := os.WriteFile(filepath.Join(b, "sub", "file.txt"), []byte("bye"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	digestA, err := TreeDigest(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestB, err := TreeDigest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digestA == digestB {
+		t.Fatal("expected trees with different file content to digest differently")
+	}
+}
+
+func TestTreeDigestSensitiveToPermByDefault(t *testing.T) {
+	a := buildDigestFixture(t, 0644)
+	b := buildDigestFixture(t, 0600)
+
+	digestA, err := TreeDigest(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestB, err := TreeDigest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digestA == digestB {
+		t.Fatal("expected trees with different permissions to digest differently by default")
+	}
+
+	digestA, err = TreeDigest(a, WithoutPerm())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestB, err = TreeDigest(b, WithoutPerm())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digestA != digestB {
+		t.Fatal("expected WithoutPerm to make differing permissions digest the same")
+	}
+}
+
+func TestTreeDigestIgnoresMtimeUnlessRequested(t *testing.T) {
+	a := buildDigestFixture(t, 0644)
+	b := buildDigestFixture(t, 0644)
+
+	backdated := time.Now().Add(-72 * time.Hour)
+	if err := os.Chtimes(filepath.Join(b, "sub", "file.txt"), backdated, backdated); err != nil {
+		t.Fatal(err)
+	}
+
+	digestA, err := TreeDigest(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestB, err := TreeDigest(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digestA != digestB {
+		t.Fatal("expected mtime to be ignored by default")
+	}
+
+	digestA, err = TreeDigest(a, WithMtime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestB, err = TreeDigest(b, WithMtime())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if digestA == digestB {
+		t.Fatal("expected WithMtime to make differing mtimes digest differently")
+	}
+}
+
+func TestTreeDigestSymlinkDefaultVsFollow(t *testing.T) {
+	root := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(root, "target.txt"), []byte("hi"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(filepath.Join(root, "target.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	withoutFollow, err := TreeDigest(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	withFollow, err := TreeDigest(root, FollowSymlinks())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if withoutFollow == withFollow {
+		t.Fatal("expected recording a symlink's target string and digesting its content to produce different digests")
+	}
+}
+
+func TestTreeDigestFollowSymlinksRejectsLoop(t *testing.T) {
+	root := t.TempDir()
+
+	if err := os.Symlink(root, filepath.Join(root, "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := TreeDigest(root, FollowSymlinks()); err == nil {
+		t.Fatal("expected an error digesting a symlink that loops back to an ancestor, got nil")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// AtomicFile is a handle returned by CreateAtomic. It behaves
+// like the file returned by ioutil.TempFile, except that Close
+// renames the file into its final place instead of leaving it
+// under its temporary name.
+type AtomicFile struct {
+	*os.File
+	path string
+	perm os.FileMode
+}
+
+// CreateAtomic creates a temporary file in the same directory as
+// path and returns a handle which writes to the temporary file.
+// Closing the handle syncs and renames the temporary file into
+// path, so that readers of path never observe a partially
+// written file. Discard abandons the write and removes the
+// temporary file instead.
+func CreateAtomic(path string, perm os.FileMode) (*AtomicFile, error) {
+
+	dir := filepath.Dir(path)
+
+	f, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &AtomicFile{File: f, path: path, perm: perm}, nil
+}
+
+// Discard closes the handle and removes its temporary file
+// without touching the destination path. It is safe to call
+// after a failed Write, instead of Close, to abandon the atomic
+// write.
+func (a *AtomicFile) Discard() error {
+	name := a.File.Name()
+
+	err := a.File.Close()
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+
+	return err
+}
+
+// Close syncs the temporary file, applies perm, and renames it
+// into place at the destination path given to CreateAtomic. If
+// any step fails, the temporary file is removed and the
+// destination path is left untouched.
+func (a *AtomicFile) Close() error {
+	name := a.File.Name()
+
+	if err := a.File.Sync(); err != nil {
+		a.File.Close()
+		os.Remove(name)
+		return err
+	}
+
+	if err := a.File.Close(); err != nil {
+		os.Remove(name)
+		return err
+	}
+
+	if err := os.Chmod(name, a.perm); err != nil {
+		os.Remove(name)
+		return err
+	}
+
+	if err := os.Rename(name, a.path); err != nil {
+		os.Remove(name)
+		return err
+	}
+
+	return nil
+}
+
+// WriteFileAtomic writes data to a temporary file in the same
+// directory as path and renames it into place, following the
+// write-temp-then-rename pattern. Unlike ioutil.WriteFile, it
+// never leaves readers of path able to observe a partially
+// written file, even if the process is interrupted mid-write or
+// another goroutine is reading path concurrently.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+
+	f, err := CreateAtomic(path, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Discard()
+		return err
+	}
+
+	return f.Close()
+}
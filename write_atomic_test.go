@@ -0,0 +1,139 @@
+// Copyright 2017 Vlad Didenko. All rights reserved.
+// See the included LICENSE.md file for licensing information
+
+package fst // import "go.didenko.com/fst"
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomicRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+
+	if err := WriteFileAtomic(path, []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "hello" {
+		t.Fatalf("expected content %q, got %q", "hello", data)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("expected mode 0640, got %o", fi.Mode().Perm())
+	}
+}
+
+func TestWriteFileAtomicOverwritesExistingFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileAtomic(path, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "new" {
+		t.Fatalf("expected content %q, got %q", "new", data)
+	}
+}
+
+func TestWriteFileAtomicNoPartialWriteBeforeClose(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := CreateAtomic(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write([]byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Before Close renames the temp file into place, a reader of
+	// path must still see the original content, not a partial
+	// write.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "original" {
+		t.Fatalf("expected readers to see the original content %q before Close, got %q", "original", data)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "partial" {
+		t.Fatalf("expected content %q after Close, got %q", "partial", data)
+	}
+}
+
+func TestAtomicFileDiscard(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+
+	if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := CreateAtomic(path, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := f.Write([]byte("abandoned")); err != nil {
+		t.Fatal(err)
+	}
+
+	tempName := f.File.Name()
+
+	if err := f.Discard(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(tempName); !os.IsNotExist(err) {
+		t.Fatalf("expected the temporary file to be removed after Discard, got err=%v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "original" {
+		t.Fatalf("expected Discard to leave path untouched with content %q, got %q", "original", data)
+	}
+}